@@ -0,0 +1,82 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+type azureStorage struct {
+	pipeline pipeline.Pipeline
+	url      string
+}
+
+func newAzureStorage(cfg *AzureConfig) (Storage, error) {
+	if cfg == nil {
+		cfg = &AzureConfig{}
+	}
+
+	accountName := cfg.AccountName
+	if accountName == "" {
+		accountName = os.Getenv("AZURE_STORAGE_ACCOUNT")
+	}
+	accountKey := cfg.AccountKey
+	if accountKey == "" {
+		accountKey = os.Getenv("AZURE_STORAGE_KEY")
+	}
+	if accountName == "" || accountKey == "" {
+		return nil, fmt.Errorf("azblob storage requires 'storage.azure.account_name'/'account_key', or the AZURE_STORAGE_ACCOUNT/AZURE_STORAGE_KEY environment variables")
+	}
+
+	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	if err != nil {
+		return nil, err
+	}
+
+	p := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+
+	return &azureStorage{
+		pipeline: p,
+		url:      fmt.Sprintf("https://%s.blob.core.windows.net", accountName),
+	}, nil
+}
+
+func (a *azureStorage) blobURL(bucket, key string) (azblob.BlockBlobURL, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/%s", a.url, bucket, key))
+	if err != nil {
+		return azblob.BlockBlobURL{}, err
+	}
+	return azblob.NewBlockBlobURL(*u, a.pipeline), nil
+}
+
+func (a *azureStorage) Reader(bucket, key string) (io.ReadCloser, error) {
+	blobURL, err := a.blobURL(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	resp, err := blobURL.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body(azblob.RetryReaderOptions{}), nil
+}
+
+func (a *azureStorage) Writer(bucket, key string) (io.WriteCloser, error) {
+	blobURL, err := a.blobURL(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return newUploadWriter(func(r io.Reader) error {
+		_, err := azblob.UploadStreamToBlockBlob(context.Background(), r, blobURL, azblob.UploadStreamToBlockBlobOptions{})
+		return err
+	}), nil
+}
@@ -0,0 +1,97 @@
+package blob
+
+import (
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// multiCloser closes an outer stream (e.g. a gzip.Reader) followed by the
+// underlying blob stream it wraps.
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var firstErr error
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// wrapDecompress wraps rc with a decompressing reader chosen by key's
+// suffix, or returns rc unchanged if the suffix is unrecognized.
+func wrapDecompress(key string, rc io.ReadCloser) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(key, ".gz"):
+		gz, err := gzip.NewReader(rc)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		return &multiCloser{Reader: gz, closers: []io.Closer{gz, rc}}, nil
+	case strings.HasSuffix(key, ".zst"):
+		dec, err := zstd.NewReader(rc)
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		return &multiCloser{Reader: dec.IOReadCloser(), closers: []io.Closer{dec.IOReadCloser(), rc}}, nil
+	default:
+		return rc, nil
+	}
+}
+
+// multiWriteCloser closes an outer compressing writer before flushing the
+// close down to the underlying blob stream.
+type multiWriteCloser struct {
+	io.Writer
+	closers []io.Closer
+}
+
+func (m *multiWriteCloser) Close() error {
+	var firstErr error
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// wrapCompress wraps wc with a compressing writer chosen by key's suffix,
+// or returns wc unchanged if the suffix is unrecognized.
+func wrapCompress(key string, wc io.WriteCloser) (io.WriteCloser, error) {
+	switch {
+	case strings.HasSuffix(key, ".gz"):
+		gz := gzip.NewWriter(wc)
+		return &multiWriteCloser{Writer: gz, closers: []io.Closer{gz, wc}}, nil
+	case strings.HasSuffix(key, ".zst"):
+		enc, err := zstd.NewWriter(wc)
+		if err != nil {
+			wc.Close()
+			return nil, err
+		}
+		return &multiWriteCloser{Writer: enc, closers: []io.Closer{enc, wc}}, nil
+	default:
+		return wc, nil
+	}
+}
+
+// StripCompressSuffix removes a trailing ".gz"/".zst" suffix, if present,
+// so callers can infer the underlying format from what remains (e.g.
+// "data.csv.gz" -> "data.csv").
+func StripCompressSuffix(key string) string {
+	for _, suffix := range []string{".gz", ".zst"} {
+		if strings.HasSuffix(key, suffix) {
+			return strings.TrimSuffix(key, suffix)
+		}
+	}
+	return key
+}
@@ -0,0 +1,39 @@
+package blob
+
+import (
+	"context"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+type gcsStorage struct {
+	client *storage.Client
+}
+
+func newGCSStorage(cfg *GCPConfig) (Storage, error) {
+	if cfg == nil {
+		cfg = &GCPConfig{}
+	}
+
+	var opts []option.ClientOption
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.CredentialsFile))
+	}
+
+	client, err := storage.NewClient(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcsStorage{client: client}, nil
+}
+
+func (g *gcsStorage) Reader(bucket, key string) (io.ReadCloser, error) {
+	return g.client.Bucket(bucket).Object(key).NewReader(context.Background())
+}
+
+func (g *gcsStorage) Writer(bucket, key string) (io.WriteCloser, error) {
+	return g.client.Bucket(bucket).Object(key).NewWriter(context.Background()), nil
+}
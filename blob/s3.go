@@ -0,0 +1,63 @@
+package blob
+
+import (
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+type s3Storage struct {
+	sess *session.Session
+}
+
+func newS3Storage(cfg *AWSConfig) (Storage, error) {
+	if cfg == nil {
+		cfg = &AWSConfig{}
+	}
+
+	awsConfig := aws.NewConfig()
+	if cfg.Region != "" {
+		awsConfig = awsConfig.WithRegion(cfg.Region)
+	}
+	if cfg.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(cfg.Endpoint)
+	}
+	if cfg.AccessKeyID != "" || cfg.SecretAccessKey != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(
+			cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken))
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Storage{sess: sess}, nil
+}
+
+func (s *s3Storage) Reader(bucket, key string) (io.ReadCloser, error) {
+	out, err := s3.New(s.sess).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *s3Storage) Writer(bucket, key string) (io.WriteCloser, error) {
+	uploader := s3manager.NewUploader(s.sess)
+	return newUploadWriter(func(r io.Reader) error {
+		_, err := uploader.Upload(&s3manager.UploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   r,
+		})
+		return err
+	}), nil
+}
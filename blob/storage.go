@@ -0,0 +1,178 @@
+// Package blob provides a minimal abstraction over cloud object storage
+// backends (S3, Google Cloud Storage, Azure Blob Storage) so that etlcmd's
+// format processors can read and write objects without knowing which
+// provider is behind a given URL.
+package blob
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Storage is implemented by each supported cloud storage backend.
+type Storage interface {
+	// Reader returns a stream for reading the object at bucket/key.
+	Reader(bucket, key string) (io.ReadCloser, error)
+	// Writer returns a stream for writing the object at bucket/key.
+	Writer(bucket, key string) (io.WriteCloser, error)
+}
+
+// Config holds credentials and provider options for each supported
+// backend, populated from the top-level 'storage' HCL block.
+type Config struct {
+	AWS   *AWSConfig
+	GCP   *GCPConfig
+	Azure *AzureConfig
+}
+
+// AWSConfig holds credentials and options for the S3 backend. Any field
+// left empty falls back to the AWS SDK's default credential chain
+// (environment variables, shared config, instance role, etc).
+type AWSConfig struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Endpoint        string
+}
+
+// GCPConfig holds credentials and options for the GCS backend. If
+// CredentialsFile is empty, the default application credentials are used.
+type GCPConfig struct {
+	CredentialsFile string
+	ProjectID       string
+}
+
+// AzureConfig holds credentials and options for the Azure Blob Storage
+// backend. Any field left empty falls back to the AZURE_STORAGE_ACCOUNT/
+// AZURE_STORAGE_KEY environment variables.
+type AzureConfig struct {
+	AccountName string
+	AccountKey  string
+}
+
+// Location is a parsed blob URL, e.g. "s3://my-bucket/path/to/key.csv.gz".
+type Location struct {
+	Scheme string
+	Bucket string
+	Key    string
+}
+
+// ParseLocation parses a blob URL of the form "scheme://bucket/key".
+func ParseLocation(raw string) (*Location, error) {
+	parts := strings.SplitN(raw, "://", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid blob URL (%s): expected scheme://bucket/key", raw)
+	}
+
+	rest := strings.SplitN(parts[1], "/", 2)
+	if len(rest) != 2 || rest[0] == "" || rest[1] == "" {
+		return nil, fmt.Errorf("invalid blob URL (%s): expected scheme://bucket/key", raw)
+	}
+
+	return &Location{Scheme: parts[0], Bucket: rest[0], Key: rest[1]}, nil
+}
+
+// IsBlobURL reports whether raw looks like a blob URL understood by this
+// package, e.g. "s3://...", "gs://...", or "azblob://...".
+func IsBlobURL(raw string) bool {
+	for _, scheme := range []string{"s3://", "gs://", "azblob://"} {
+		if strings.HasPrefix(raw, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns the Storage implementation for the given scheme ("s3",
+// "gs", or "azblob"), configured from cfg.
+func New(scheme string, cfg *Config) (Storage, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	switch scheme {
+	case "s3":
+		return newS3Storage(cfg.AWS)
+	case "gs":
+		return newGCSStorage(cfg.GCP)
+	case "azblob":
+		return newAzureStorage(cfg.Azure)
+	default:
+		return nil, fmt.Errorf("unsupported blob storage scheme (%s)", scheme)
+	}
+}
+
+// uploadWriter is an io.WriteCloser backed by an io.Pipe whose read side
+// is drained by an upload goroutine. Unlike a bare *io.PipeWriter, whose
+// Close always returns nil as soon as the pipe's reader sees EOF, Close
+// blocks until the upload goroutine actually finishes and reports its
+// real result.
+type uploadWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+// newUploadWriter starts upload in its own goroutine, reading from the
+// *io.PipeReader end of a pipe whose write end is the returned
+// uploadWriter, and returns upload's error (if any) from Close.
+func newUploadWriter(upload func(io.Reader) error) *uploadWriter {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := upload(pr)
+		pr.CloseWithError(err)
+		done <- err
+	}()
+	return &uploadWriter{PipeWriter: pw, done: done}
+}
+
+func (w *uploadWriter) Close() error {
+	if err := w.PipeWriter.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+// Open opens a streaming reader for rawURL, transparently decompressing
+// based on the key's suffix (".gz", ".zst").
+func Open(rawURL string, cfg *Config) (io.ReadCloser, error) {
+	loc, err := ParseLocation(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := New(loc.Scheme, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := storage.Reader(loc.Bucket, loc.Key)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %s", rawURL, err)
+	}
+
+	return wrapDecompress(loc.Key, r)
+}
+
+// Create opens a streaming writer for rawURL, transparently compressing
+// based on the key's suffix (".gz", ".zst").
+func Create(rawURL string, cfg *Config) (io.WriteCloser, error) {
+	loc, err := ParseLocation(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := New(loc.Scheme, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := storage.Writer(loc.Bucket, loc.Key)
+	if err != nil {
+		return nil, fmt.Errorf("creating %s: %s", rawURL, err)
+	}
+
+	return wrapCompress(loc.Key, w)
+}
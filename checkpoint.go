@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/licaonfee/ratchet/data"
+	"github.com/licaonfee/ratchet/processors"
+)
+
+// checkpointState is the on-disk format written by a checkpointStore.
+type checkpointState struct {
+	LastID string `json:"last_id"`
+}
+
+// checkpointStore persists the last successfully processed key/ID for a
+// resumable input to a small JSON file, so that a retried run can pick
+// up where the previous attempt left off instead of starting over.
+type checkpointStore struct {
+	path string
+}
+
+func newCheckpointStore(path string) *checkpointStore {
+	return &checkpointStore{path: path}
+}
+
+// Load returns the last checkpointed ID, or "" if no checkpoint has been
+// written yet.
+func (s *checkpointStore) Load() (string, error) {
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading checkpoint (%s): %s", s.path, err)
+	}
+
+	var state checkpointState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return "", fmt.Errorf("parsing checkpoint (%s): %s", s.path, err)
+	}
+	return state.LastID, nil
+}
+
+// Save records id as the new checkpoint, writing to a temp file and
+// renaming it into place so a crash mid-write can't corrupt it.
+func (s *checkpointStore) Save(id string) error {
+	b, err := json.Marshal(checkpointState{LastID: id})
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("writing checkpoint (%s): %s", tmp, err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// checkpointingProcessor wraps a DataProcessor, saving the value of
+// idField from the last row of every batch to store once the batch has
+// been forwarded downstream.
+type checkpointingProcessor struct {
+	processors.DataProcessor
+	store   *checkpointStore
+	idField string
+}
+
+func (c *checkpointingProcessor) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error) {
+	// Like metrics.go's observingProcessor, the wrapped input emits its
+	// rows to outputChan rather than returning them via d, so relay
+	// through a channel of our own to see each batch.
+	checkpointed := make(chan data.JSON)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for batch := range checkpointed {
+			outputChan <- batch
+			c.checkpoint(batch)
+		}
+	}()
+	c.DataProcessor.ProcessData(d, checkpointed, killChan)
+	close(checkpointed)
+	<-done
+}
+
+// checkpoint saves the value of idField from batch's last row, if any,
+// as the new checkpoint.
+func (c *checkpointingProcessor) checkpoint(batch data.JSON) {
+	var rows []map[string]interface{}
+	if err := data.ParseJSON(batch, &rows); err != nil || len(rows) == 0 {
+		return
+	}
+
+	id, ok := rows[len(rows)-1][c.idField]
+	if !ok {
+		return
+	}
+
+	if err := c.store.Save(fmt.Sprintf("%v", id)); err != nil {
+		log.Printf("  Failed to save checkpoint (%s): %s", c.store.path, err)
+	}
+}
+
+// unidataCheckpointPath extracts the 'path' attribute from a unidata
+// input's nested 'checkpoint' block, if one is present.
+func unidataCheckpointPath(blockConfig configMap) (string, error) {
+	v, ok := blockConfig["checkpoint"]
+	if !ok {
+		return "", nil
+	}
+
+	var raw map[string]interface{}
+	switch t := v.(type) {
+	case []map[string]interface{}:
+		if len(t) != 1 {
+			return "", fmt.Errorf("only one 'checkpoint' block allowed")
+		}
+		raw = t[0]
+	case map[string]interface{}:
+		raw = t
+	default:
+		return "", fmt.Errorf("invalid 'checkpoint' block")
+	}
+
+	path, ok := raw["path"].(string)
+	if !ok || path == "" {
+		return "", fmt.Errorf("you must specify a 'path' attribute for 'checkpoint'")
+	}
+	return path, nil
+}
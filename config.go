@@ -39,6 +39,30 @@ type processInfo struct {
 	Input      *inputInfo
 	Transforms []*transformInfo
 	Output     *outputInfo
+	Retry      *retryInfo
+}
+
+// retryInfo configures how many times, and with what backoff, a
+// process's pipeline is retried after a failure. It may be set at the
+// top level (applying to all processes) or per-process, where it
+// overrides the top-level setting.
+type retryInfo struct {
+	Attempts       int
+	InitialBackoff string `hcl:"initial_backoff,"`
+	MaxBackoff     string `hcl:"max_backoff,"`
+	Multiplier     float64
+}
+
+// reportInfo configures the Markdown run-summary file appended to after
+// each process completes.
+type reportInfo struct {
+	Path   string
+	Format string
+}
+
+// metricsInfo configures the HTTP server exposing Prometheus metrics.
+type metricsInfo struct {
+	Listen string
 }
 
 type unidataInfo struct {
@@ -48,12 +72,59 @@ type unidataInfo struct {
 	UdtBin   string `hcl:"udtbin,"`
 	UdtHome  string `hcl:"udthome,"`
 	UdtAcct  string `hcl:"udtacct,"`
+
+	KnownHosts               string   `hcl:"known_hosts,"`
+	HostKeyAlgorithms        []string `hcl:"host_key_algorithms,"`
+	InsecureSkipHostKeyCheck bool     `hcl:"insecure_skip_host_key_check,"`
+
+	PrivateKey           string `hcl:"private_key,"`
+	PrivateKeyPassphrase string `hcl:"private_key_passphrase,"`
+	SSHAgent             bool   `hcl:"ssh_agent,"`
+}
+
+// storageInfo represents credentials and options for the cloud blob
+// storage backends used by the 's3', 'gcs', and 'azblob' input/output
+// types.
+type storageInfo struct {
+	AWS   *awsStorageInfo
+	GCP   *gcpStorageInfo
+	Azure *azureStorageInfo
+}
+
+type awsStorageInfo struct {
+	Region          string
+	AccessKeyID     string `hcl:"access_key_id,"`
+	SecretAccessKey string `hcl:"secret_access_key,"`
+	SessionToken    string `hcl:"session_token,"`
+	Endpoint        string
+}
+
+type gcpStorageInfo struct {
+	CredentialsFile string `hcl:"credentials_file,"`
+	ProjectID       string `hcl:"project_id,"`
+}
+
+type azureStorageInfo struct {
+	AccountName string `hcl:"account_name,"`
+	AccountKey  string `hcl:"account_key,"`
+}
+
+// extensionInfo represents an out-of-process processor registered via a
+// top-level 'extension' block.
+type extensionInfo struct {
+	Name    string `hcl:",key"`
+	Command string
 }
 
 // Config is the root configuration object that contains all ETL process specifications
 type Config struct {
-	Processes []*processInfo `hcl:"process,"`
-	Unidata   *unidataInfo
+	Processes  []*processInfo `hcl:"process,"`
+	Unidata    *unidataInfo
+	Storage    *storageInfo
+	Extensions []*extensionInfo `hcl:"extension,"`
+	Retry      *retryInfo
+	Report     *reportInfo
+	Metrics    *metricsInfo
 }
 
 // Parse consumes a Reader and returns a Config object
@@ -82,6 +153,11 @@ func Parse(r io.Reader) (*Config, error) {
 	valid := []string{
 		"process",
 		"unidata",
+		"storage",
+		"extension",
+		"retry",
+		"report",
+		"metrics",
 	}
 	if err := checkHCLKeys(list, valid); err != nil {
 		return nil, err
@@ -105,6 +181,75 @@ func Parse(r io.Reader) (*Config, error) {
 		}
 	}
 
+	// Parse the storage config
+	if o := list.Filter("storage"); len(o.Items) > 0 {
+
+		if err := parseStorage(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'storage': %s", err)
+		}
+	}
+
+	// Parse the extension configs
+	if o := list.Filter("extension"); len(o.Items) > 0 {
+
+		if err := parseExtensions(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'extension': %s", err)
+		}
+	}
+
+	// Parse the top-level retry config
+	if o := list.Filter("retry"); len(o.Items) > 0 {
+
+		if len(o.Items) > 1 {
+			return nil, fmt.Errorf("only one top-level 'retry' block allowed")
+		}
+
+		var r retryInfo
+		if err := decodeHCLBlockObject(o.Items[0].Val, &r); err != nil {
+			return nil, fmt.Errorf("error parsing 'retry': %s", err)
+		}
+		result.Retry = &r
+	}
+
+	// Parse the top-level report config
+	if o := list.Filter("report"); len(o.Items) > 0 {
+
+		if len(o.Items) > 1 {
+			return nil, fmt.Errorf("only one 'report' block allowed")
+		}
+
+		var r reportInfo
+		if err := decodeHCLBlockObject(o.Items[0].Val, &r); err != nil {
+			return nil, fmt.Errorf("error parsing 'report': %s", err)
+		}
+		if r.Path == "" {
+			return nil, fmt.Errorf("you must specify a 'path' attribute for 'report'")
+		}
+		if r.Format == "" {
+			r.Format = "markdown"
+		} else if r.Format != "markdown" {
+			return nil, fmt.Errorf("unsupported 'format' (%s) for 'report', only 'markdown' is supported", r.Format)
+		}
+		result.Report = &r
+	}
+
+	// Parse the top-level metrics config
+	if o := list.Filter("metrics"); len(o.Items) > 0 {
+
+		if len(o.Items) > 1 {
+			return nil, fmt.Errorf("only one 'metrics' block allowed")
+		}
+
+		var m metricsInfo
+		if err := decodeHCLBlockObject(o.Items[0].Val, &m); err != nil {
+			return nil, fmt.Errorf("error parsing 'metrics': %s", err)
+		}
+		if m.Listen == "" {
+			return nil, fmt.Errorf("you must specify a 'listen' attribute for 'metrics'")
+		}
+		result.Metrics = &m
+	}
+
 	return &result, nil
 }
 
@@ -118,7 +263,11 @@ func parseUnidata(result *Config, list *ast.ObjectList) error {
 	item := list.Items[0]
 
 	// Check for invalid keys
-	valid := []string{"host", "username", "password", "udtbin", "udthome", "udtacct"}
+	valid := []string{
+		"host", "username", "password", "udtbin", "udthome", "udtacct",
+		"known_hosts", "host_key_algorithms", "insecure_skip_host_key_check",
+		"private_key", "private_key_passphrase", "ssh_agent",
+	}
 	if err := checkHCLKeys(item.Val, valid); err != nil {
 		return multierror.Prefix(err, "unidata:")
 	}
@@ -133,6 +282,107 @@ func parseUnidata(result *Config, list *ast.ObjectList) error {
 	return mapstructure.WeakDecode(m, &u)
 }
 
+func parseStorage(result *Config, list *ast.ObjectList) error {
+
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'storage' block allowed")
+	}
+
+	item := list.Items[0]
+
+	// Check for invalid keys
+	valid := []string{"aws", "gcp", "azure"}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, "storage:")
+	}
+
+	ot, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return fmt.Errorf("'storage' should be an object")
+	}
+
+	s := storageInfo{}
+
+	if o := ot.List.Filter("aws"); len(o.Items) > 0 {
+		aws := awsStorageInfo{}
+		if err := decodeHCLBlockObject(o.Items[0].Val, &aws); err != nil {
+			return multierror.Prefix(err, "storage.aws:")
+		}
+		s.AWS = &aws
+	}
+
+	if o := ot.List.Filter("gcp"); len(o.Items) > 0 {
+		gcp := gcpStorageInfo{}
+		if err := decodeHCLBlockObject(o.Items[0].Val, &gcp); err != nil {
+			return multierror.Prefix(err, "storage.gcp:")
+		}
+		s.GCP = &gcp
+	}
+
+	if o := ot.List.Filter("azure"); len(o.Items) > 0 {
+		azure := azureStorageInfo{}
+		if err := decodeHCLBlockObject(o.Items[0].Val, &azure); err != nil {
+			return multierror.Prefix(err, "storage.azure:")
+		}
+		s.Azure = &azure
+	}
+
+	result.Storage = &s
+	return nil
+}
+
+func parseExtensions(result *Config, list *ast.ObjectList) error {
+
+	list = list.Children()
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	collection := make([]*extensionInfo, 0, len(list.Items))
+	seen := make(map[string]struct{})
+	for _, item := range list.Items {
+		n, ok := item.Keys[0].Token.Value().(string)
+		if !ok {
+			return fmt.Errorf("extension name must be a string, got %q", item.Keys[0].Token.Value())
+		}
+
+		if _, ok := seen[n]; ok {
+			return fmt.Errorf("extension '%s' defined more than once", n)
+		}
+		seen[n] = struct{}{}
+
+		valid := []string{"command"}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("extension '%s':", n))
+		}
+
+		var e extensionInfo
+		if err := decodeHCLBlockObject(item.Val, &e); err != nil {
+			return err
+		}
+		e.Name = n
+
+		if e.Command == "" {
+			return fmt.Errorf("extension '%s': you must specify a 'command' attribute", n)
+		}
+
+		collection = append(collection, &e)
+	}
+
+	result.Extensions = collection
+	return nil
+}
+
+// decodeHCLBlockObject decodes a single nested HCL block (e.g. the 'aws'
+// block inside 'storage') into a Go struct via mapstructure.
+func decodeHCLBlockObject(node ast.Node, out interface{}) error {
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, node); err != nil {
+		return err
+	}
+	return mapstructure.WeakDecode(m, out)
+}
+
 func parseProcesses(result *Config, list *ast.ObjectList) error {
 
 	list = list.Children()
@@ -156,7 +406,7 @@ func parseProcesses(result *Config, list *ast.ObjectList) error {
 		seen[n] = struct{}{}
 
 		// Check for invalid keys
-		valid := []string{"input", "output", "transform"}
+		valid := []string{"input", "output", "transform", "retry"}
 		if err := checkHCLKeys(item.Val, valid); err != nil {
 			return multierror.Prefix(err, fmt.Sprintf(
 				"process '%s':", n))
@@ -199,6 +449,19 @@ func parseProcesses(result *Config, list *ast.ObjectList) error {
 			return fmt.Errorf("error parsing 'output': %s", err)
 		}
 
+		// Parse the per-process retry override
+		if o := listVal.Filter("retry"); len(o.Items) > 0 {
+			if len(o.Items) > 1 {
+				return fmt.Errorf("only one 'retry' block allowed for process '%s'", process.Name)
+			}
+
+			var r retryInfo
+			if err := decodeHCLBlockObject(o.Items[0].Val, &r); err != nil {
+				return fmt.Errorf("error parsing 'retry' for process '%s': %s", process.Name, err)
+			}
+			process.Retry = &r
+		}
+
 		collection = append(collection, &process)
 	}
 
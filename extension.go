@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/licaonfee/ratchet/data"
+	"github.com/licaonfee/ratchet/processors"
+)
+
+// extensionProcessor adapts an out-of-process extension binary, speaking
+// JSON-RPC 2.0 over stdio, to the processors.DataProcessor interface. One
+// is spawned for each 'input'/'transform'/'output' block that uses the
+// extension.
+type extensionProcessor struct {
+	name  string
+	kind  string // "input", "transform", or "output"
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	rpc   *jsonrpcClient
+}
+
+// newExtensionProcessor starts command as a subprocess, connects a
+// jsonrpcClient to its stdin/stdout, and calls its Init RPC with
+// blockConfig. kind is "input", "transform", or "output", and
+// determines how ProcessData drives the extension.
+func newExtensionProcessor(name, kind, command string, blockConfig configMap) (*extensionProcessor, error) {
+	cmd := exec.Command(command)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("extension '%s': creating stdin pipe: %s", name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("extension '%s': creating stdout pipe: %s", name, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("extension '%s': starting (%s): %s", name, command, err)
+	}
+
+	ep := &extensionProcessor{
+		name:  name,
+		kind:  kind,
+		cmd:   cmd,
+		stdin: stdin,
+		rpc:   newJSONRPCClient(stdin, stdout),
+	}
+
+	if err := ep.rpc.Call("Init", blockConfig, nil); err != nil {
+		ep.kill()
+		return nil, fmt.Errorf("extension '%s': Init: %s", name, err)
+	}
+
+	return ep, nil
+}
+
+func (e *extensionProcessor) kill() {
+	e.stdin.Close()
+	e.cmd.Process.Kill()
+	e.cmd.Wait()
+}
+
+// ProcessData drives the extension's ProcessData RPC. For a transform
+// or output stage, d holds the batch of rows to forward, and whatever
+// rows the call returns are sent to outputChan. For an input stage, d
+// is ratchet's StartSignal rather than row data, so it instead repeats
+// the call with no input rows until the extension reports it has none
+// left, the same way the built-in readers ignore d and drive outputChan
+// themselves.
+func (e *extensionProcessor) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error) {
+	if e.kind == "input" {
+		e.processInput(outputChan, killChan)
+		return
+	}
+
+	var rows []map[string]interface{}
+	if err := data.ParseJSON(d, &rows); err != nil {
+		killChan <- fmt.Errorf("extension '%s': decoding input rows: %s", e.name, err)
+		return
+	}
+
+	result, err := e.callProcessData(rows, killChan)
+	if err != nil {
+		return
+	}
+
+	out, err := data.NewJSON(result)
+	if err != nil {
+		killChan <- fmt.Errorf("extension '%s': encoding output rows: %s", e.name, err)
+		return
+	}
+	outputChan <- out
+}
+
+// processInput repeatedly calls ProcessData with no input rows, sending
+// each non-empty result to outputChan, until the extension signals it
+// has no more rows by returning an empty result.
+func (e *extensionProcessor) processInput(outputChan chan data.JSON, killChan chan error) {
+	for {
+		result, err := e.callProcessData(nil, killChan)
+		if err != nil {
+			return
+		}
+		if len(result) == 0 {
+			return
+		}
+
+		out, err := data.NewJSON(result)
+		if err != nil {
+			killChan <- fmt.Errorf("extension '%s': encoding output rows: %s", e.name, err)
+			return
+		}
+		outputChan <- out
+	}
+}
+
+// callProcessData calls the extension's ProcessData RPC with rows,
+// reporting any error to killChan and returning it so the caller can
+// stop.
+func (e *extensionProcessor) callProcessData(rows []map[string]interface{}, killChan chan error) ([]map[string]interface{}, error) {
+	var result []map[string]interface{}
+	if err := e.rpc.Call("ProcessData", rows, &result); err != nil {
+		err = fmt.Errorf("extension '%s': ProcessData: %s", e.name, err)
+		killChan <- err
+		return nil, err
+	}
+	return result, nil
+}
+
+// Finish calls the extension's Finish RPC, then closes its stdin and
+// waits for the subprocess to exit.
+func (e *extensionProcessor) Finish(outputChan chan data.JSON, killChan chan error) {
+	err := e.rpc.Call("Finish", nil, nil)
+
+	e.stdin.Close()
+	waitErr := e.cmd.Wait()
+
+	if err != nil {
+		killChan <- fmt.Errorf("extension '%s': Finish: %s", e.name, err)
+		return
+	}
+	if waitErr != nil {
+		killChan <- fmt.Errorf("extension '%s': subprocess exited with error: %s", e.name, waitErr)
+	}
+}
+
+// registerExtensions registers an input/transform/output factory for
+// each configured 'extension' block, so that 'input "myext" { ... }'
+// (and the transform/output equivalents) route to the extension binary.
+func registerExtensions(r *ProcessorRegistry, extensions []*extensionInfo) {
+	for _, ext := range extensions {
+		ext := ext
+		factory := func(kind string) processorFactory {
+			return func(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+				return newExtensionProcessor(ext.Name, kind, ext.Command, blockConfig)
+			}
+		}
+		r.RegisterInput(ext.Name, factory("input"))
+		r.RegisterTransform(ext.Name, factory("transform"))
+		r.RegisterOutput(ext.Name, factory("output"))
+	}
+}
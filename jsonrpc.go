@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+type jsonrpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      uint64      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+}
+
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *jsonrpcError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// jsonrpcClient is a minimal JSON-RPC 2.0 client that speaks newline-
+// delimited JSON requests/responses over a pair of stdio streams, used
+// to talk to 'extension' processor subprocesses.
+type jsonrpcClient struct {
+	mu     sync.Mutex
+	nextID uint64
+	enc    *json.Encoder
+	dec    *json.Decoder
+}
+
+func newJSONRPCClient(w io.Writer, r io.Reader) *jsonrpcClient {
+	return &jsonrpcClient{
+		enc: json.NewEncoder(w),
+		dec: json.NewDecoder(bufio.NewReader(r)),
+	}
+}
+
+// Call sends method with params and decodes the response's result into
+// result (which may be nil). Calls are serialized: only one request is
+// in flight at a time, which is sufficient for the single pipeline
+// stage each extension process backs.
+func (c *jsonrpcClient) Call(method string, params interface{}, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	req := jsonrpcRequest{JSONRPC: "2.0", ID: c.nextID, Method: method, Params: params}
+	if err := c.enc.Encode(&req); err != nil {
+		return fmt.Errorf("writing jsonrpc request: %s", err)
+	}
+
+	var resp jsonrpcResponse
+	if err := c.dec.Decode(&resp); err != nil {
+		return fmt.Errorf("reading jsonrpc response: %s", err)
+	}
+	if resp.ID != req.ID {
+		return fmt.Errorf("jsonrpc response id mismatch: sent %d, got %d", req.ID, resp.ID)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("decoding jsonrpc result: %s", err)
+		}
+	}
+	return nil
+}
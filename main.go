@@ -1,22 +1,21 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/codegangsta/cli"
-	"github.com/rhansen2/ratchet"
-	"github.com/rhansen2/ratchet/logger"
-	"github.com/rhansen2/ratchet/processors"
-	"github.com/rhansen2/ratchet/util"
-	"golang.org/x/crypto/ssh"
+	"github.com/licaonfee/ratchet"
+	"github.com/licaonfee/ratchet/logger"
+	"github.com/licaonfee/ratchet/processors"
+	"github.com/licaonfee/ratchet/util"
+	"github.com/urfave/cli"
 
-	procs "github.com/samhug/ratchet_processors"
-	"github.com/samhug/udt"
+	"github.com/samhug/etlcmd/blob"
 )
 
 const (
@@ -87,265 +86,230 @@ func main() {
 	}
 
 	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
 
 // Get handle to input file defined by path attribute, if defined, or stdin
-func inputFile(config configMap) (f *os.File) {
-	if config["path"] != nil {
-		var err error
-		path := config["path"].(string)
-		f, err = os.Open(path)
-		if err != nil {
-			log.Fatalf("Failed to open input file (%s): %s", path, err)
-		}
-	} else {
-		f = os.Stdin
+func inputFile(config configMap) (*os.File, error) {
+	if config["path"] == nil {
+		return os.Stdin, nil
 	}
-	return
+	path := config["path"].(string)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file (%s): %s", path, err)
+	}
+	return f, nil
 }
 
 // Get handle to output file defined by path attribute, if defined, or stdout
-func outputFile(config configMap) (f *os.File) {
-	if config["path"] != nil {
-		var err error
-		path := config["path"].(string)
-		f, err = os.Create(path)
-		if err != nil {
-			log.Fatalf("Failed to create output file (%s): %s", path, err)
-		}
-	} else {
-		f = os.Stdout
+func outputFile(config configMap) (*os.File, error) {
+	if config["path"] == nil {
+		return os.Stdout, nil
+	}
+	path := config["path"].(string)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file (%s): %s", path, err)
 	}
-	return
+	return f, nil
 }
 
-func runApp(config *Config) error {
-
-	logger.LogLevel = logger.LevelError
-
-	// Initialize ETL's
-	for _, processInfo := range config.Processes {
-
-		log.Printf("%s ETL Process", processInfo.Name)
-
-		var processorChain []ratchet.DataProcessor
-		var err error
+// blobStorageConfig converts the parsed 'storage' HCL block into the
+// blob.Config consumed by the blob package.
+func blobStorageConfig(config *Config) *blob.Config {
+	cfg := &blob.Config{}
+	if config.Storage == nil {
+		return cfg
+	}
+	if a := config.Storage.AWS; a != nil {
+		cfg.AWS = &blob.AWSConfig{
+			Region:          a.Region,
+			AccessKeyID:     a.AccessKeyID,
+			SecretAccessKey: a.SecretAccessKey,
+			SessionToken:    a.SessionToken,
+			Endpoint:        a.Endpoint,
+		}
+	}
+	if g := config.Storage.GCP; g != nil {
+		cfg.GCP = &blob.GCPConfig{
+			CredentialsFile: g.CredentialsFile,
+			ProjectID:       g.ProjectID,
+		}
+	}
+	if az := config.Storage.Azure; az != nil {
+		cfg.Azure = &blob.AzureConfig{
+			AccountName: az.AccountName,
+			AccountKey:  az.AccountKey,
+		}
+	}
+	return cfg
+}
 
-		// Initialize Input
-		var input ratchet.DataProcessor
-		inputType := strings.ToLower(processInfo.Input.Type)
-		inputConfig := processInfo.Input.Config
+// blobLocationAndFormat extracts the bucket and key and resolves the data
+// format (csv, json, or jsonl) for an 's3'/'gcs'/'azblob' input or output
+// block. The
+// format may be given explicitly via a 'format' attribute, and otherwise
+// is inferred from the key's suffix.
+func blobLocationAndFormat(blobType string, config configMap) (bucket, key, format string, err error) {
+	bucketField, ok := config["bucket"]
+	if !ok {
+		return "", "", "", fmt.Errorf("you must specify a 'bucket' attribute for %s input/output", blobType)
+	}
+	bucket, ok = bucketField.(string)
+	if !ok {
+		return "", "", "", fmt.Errorf("the 'bucket' attribute for %s input/output must be a string", blobType)
+	}
 
-		log.Printf("  Initializing %s input", inputType)
+	keyField, ok := config["key"]
+	if !ok {
+		return "", "", "", fmt.Errorf("you must specify a 'key' attribute for %s input/output", blobType)
+	}
+	key, ok = keyField.(string)
+	if !ok {
+		return "", "", "", fmt.Errorf("the 'key' attribute for %s input/output must be a string", blobType)
+	}
 
-		switch inputType {
-		default:
-			log.Fatalf("Unsupported input type (%s)\n", inputType)
-		case "csv":
-			f := inputFile(inputConfig)
-			defer f.Close()
+	if formatField, ok := config["format"]; ok {
+		format, ok = formatField.(string)
+		if !ok {
+			return "", "", "", fmt.Errorf("the 'format' attribute for %s input/output must be a string", blobType)
+		}
+	} else {
+		base := blob.StripCompressSuffix(key)
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(base)), ".")
+	}
 
-			input, err = procs.NewCSVReader(f)
-			if err != nil {
-				log.Fatalf("Failed to initialize input: %s\n", err)
-			}
-		case "json":
-			f := inputFile(inputConfig)
-			defer f.Close()
+	return bucket, key, format, nil
+}
 
-			input = procs.NewJSONReader(f)
-		case "jsonl":
-			f := inputFile(inputConfig)
-			defer f.Close()
+func blobURL(blobType, bucket, key string) string {
+	scheme := map[string]string{"s3": "s3", "gcs": "gs", "azblob": "azblob"}[blobType]
+	return fmt.Sprintf("%s://%s/%s", scheme, bucket, key)
+}
 
-			input = procs.NewJSONLReader(f)
+func runApp(config *Config) error {
 
-		case "unidata":
-			udtEnv := &procs.UdtEnvConfig{}
-			udtEnv.UdtBin = config.Unidata.UdtBin
-			udtEnv.UdtHome = config.Unidata.UdtHome
-			udtEnv.UdtAcct = config.Unidata.UdtAcct
+	logger.LogLevel = logger.LevelError
 
-			if udtEnv.UdtBin == "" {
-				log.Fatalf("The 'udtbin' attribute for input type 'unidata' must not be empty")
-			}
-			if udtEnv.UdtHome == "" {
-				log.Fatalf("The 'udthome' attribute for input type 'unidata' must not be empty")
-			}
-			if udtEnv.UdtAcct == "" {
-				log.Fatalf("The 'udtacct' attribute for input type 'unidata' must not be empty")
-			}
+	registry := newProcessorRegistry()
+	registerExtensions(registry, config.Extensions)
 
-			fileField, ok := inputConfig["file"]
-			if !ok {
-				log.Fatalf("You must specify a 'file' attribute for input type 'unidata'")
-			}
-			file, ok := fileField.(string)
-			if !ok {
-				log.Fatalf("The 'file' attribute for input type 'unidata' must be a string")
-			}
+	if config.Metrics != nil {
+		if err := startMetricsServer(config.Metrics); err != nil {
+			return err
+		}
+	}
 
-			// If there is a select statement provided, use it. Otherwise, default to selecting the whole file.
-			var selectScript []string
-			selectStmtField, ok := inputConfig["select"]
-			if ok {
-				// Check if we were given a list
-				selectInterface, ok := selectStmtField.([]interface{})
-				if ok {
-					selectScript = make([]string, len(selectInterface))
-					for i, v := range selectInterface {
-						selectScript[i], ok = v.(string)
-						if !ok {
-							log.Fatalf("The 'select' attribute for input type 'unidata' must be a string or an array of strings")
-						}
-					}
-
-				} else {
-					// Check if we were given a single string
-					selectStmt, ok := selectStmtField.(string)
-					if !ok {
-						log.Fatalf("The 'select' attribute for input type 'unidata' must be a string or an array of strings")
-					}
-					selectScript = []string{selectStmt}
-				}
-			} else {
-				selectScript = []string{fmt.Sprintf("SELECT %s", file)}
-			}
+	// Run each ETL process, retrying according to its (possibly
+	// inherited) retry policy, and keep going so that one process's
+	// exhausted retries don't prevent the rest from running.
+	var failed []string
+	for _, processInfo := range config.Processes {
 
-			fieldsField, ok := inputConfig["fields"]
-			if !ok {
-				log.Fatalf("You must specify a 'fields' attribute for input type 'unidata'")
-			}
-			fieldsInterface, ok := fieldsField.([]interface{})
-			if !ok {
-				log.Fatalf("The 'fields' attribute for input type 'unidata' must be a list of strings")
-			}
-			fields := make([]string, len(fieldsInterface))
-			for i, v := range fieldsInterface {
-				fields[i], ok = v.(string)
-				if !ok {
-					log.Fatalf("The 'fields' attribute for input type 'unidata' must be a list of strings")
-				}
-			}
+		log.Printf("%s ETL Process", processInfo.Name)
 
-			batchSize := 10000
-			batchSizeField, ok := inputConfig["batch_size"]
-			if ok {
-				batchSize, ok = batchSizeField.(int)
-				if !ok {
-					log.Fatalf("The 'batch_size' attribute for input type 'unidata' must be an int")
-				}
-			}
+		retryConf := processInfo.Retry
+		if retryConf == nil {
+			retryConf = config.Retry
+		}
+		policy, err := resolveRetryPolicy(retryConf)
+		if err != nil {
+			return fmt.Errorf("process '%s': %s", processInfo.Name, err)
+		}
 
-			queryConfig := &procs.UdtQueryConfig{
-				Select:    selectScript,
-				File:      file,
-				Fields:    fields,
-				BatchSize: batchSize,
-			}
+		var stats processStats
+		err = runWithRetry(processInfo.Name, policy, func() error {
+			stats = processStats{Name: processInfo.Name}
+			return runProcess(registry, config, processInfo, &stats)
+		})
+		stats.Err = err
 
-			sshConfig := &ssh.ClientConfig{
-				User: config.Unidata.Username,
-				Auth: []ssh.AuthMethod{
-					ssh.Password(config.Unidata.Password),
-				},
-				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-			}
+		if err != nil {
+			processErrorsTotal.WithLabelValues(processInfo.Name).Inc()
+			failed = append(failed, err.Error())
+		} else {
+			log.Printf(" Done...")
+		}
 
-			sshClient, err := ssh.Dial("tcp", config.Unidata.Host, sshConfig)
-			if err != nil {
-				log.Fatalf("Failed to connect to (%s) as user (%s): %s", config.Unidata.Host, config.Unidata.Username, err)
-			}
-			defer sshClient.Close()
+		if rerr := appendReport(config.Report, &stats); rerr != nil {
+			log.Printf("  Failed to write run summary: %s", rerr)
+		}
+	}
 
-			udtClient := udt.NewClient(sshClient, udtEnv)
+	if len(failed) > 0 {
+		return fmt.Errorf("%d process(es) failed: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
 
-			input, err = procs.NewUdtReader(udtClient, queryConfig)
-			if err != nil {
-				log.Fatalf("Failed to initialize input: %s\n", err)
-			}
+// runProcess builds the input/transform/output chain for a single
+// process, runs its pipeline once, and returns any error encountered
+// instead of exiting, so that runApp's retry loop can act on it. stats
+// is populated as the chain runs, for use in the Markdown report.
+func runProcess(registry *ProcessorRegistry, config *Config, processInfo *processInfo, stats *processStats) error {
+	start := time.Now()
+	defer func() {
+		stats.Duration = time.Since(start)
+		processDurationSeconds.WithLabelValues(processInfo.Name).Observe(stats.Duration.Seconds())
+	}()
+
+	var processorChain []processors.DataProcessor
+	var closers []io.Closer
+	ctx := &factoryContext{Config: config, closers: &closers}
+	defer func() {
+		for _, c := range closers {
+			c.Close()
 		}
-		processorChain = append(processorChain, input)
-
-		// Initialize Transformations
-		for _, transformInfo := range processInfo.Transforms {
-			var transform ratchet.DataProcessor
-			transformType := strings.ToLower(transformInfo.Type)
-			transformConfig := transformInfo.Config
-
-			log.Printf("  Initializing %s transform", transformType)
-
-			switch transformType {
-			default:
-				log.Fatalf("Unsupported transform type (%s)\n", transformType)
-			case "js":
-				script := transformConfig["script"].(string)
-				transform, err = procs.NewJsTransform(script)
-				if err != nil {
-					log.Fatalf("Failed to initialize JS transform: %s", err)
-				}
-			}
-			processorChain = append(processorChain, transform)
-		}
-
-		// Initialize Output
-		var output ratchet.DataProcessor
-		outputType := strings.ToLower(processInfo.Output.Type)
-		outputConfig := processInfo.Output.Config
+	}()
 
-		log.Printf("  Initializing %s output", outputType)
+	// Initialize Input
+	inputType := strings.ToLower(processInfo.Input.Type)
+	stats.InputType = inputType
+	log.Printf("  Initializing %s input", inputType)
 
-		switch outputType {
-		default:
-			log.Fatalf("Unsupported output type (%s)\n", outputType)
-		case "csv":
-			f := outputFile(outputConfig)
-			defer f.Close()
+	input, err := registry.Input(ctx, inputType, processInfo.Input.Config)
+	if err != nil {
+		return fmt.Errorf("initializing input: %s", err)
+	}
+	processorChain = append(processorChain, observeProcessor(processInfo.Name, "input", inputType, input, &stats.RowsIn))
 
-			var columnOrder []string
-			if outputConfig["column_order"] != nil {
-				v, ok := outputConfig["column_order"].([]interface{})
-				if !ok {
-					log.Fatal("Field 'column_order' for csv output must be and array of strings")
-				}
-				for i, c := range v {
-					h, ok := c.(string)
-					if !ok {
-						log.Fatalf("Field 'column_order' for csv output: item %d must be a string", i)
-					}
-					columnOrder = append(columnOrder, h)
-				}
-			}
+	// Initialize Transformations
+	for _, transformInfo := range processInfo.Transforms {
+		transformType := strings.ToLower(transformInfo.Type)
+		stats.Transforms = append(stats.Transforms, transformType)
+		log.Printf("  Initializing %s transform", transformType)
 
-			output = newCSVWriter(f, columnOrder)
-		case "json":
-			f := outputFile(outputConfig)
-			defer f.Close()
-			output = procs.NewJSONWriter(f)
-		case "jsonl":
-			f := outputFile(outputConfig)
-			defer f.Close()
-			output = procs.NewJSONLWriter(f)
+		transform, err := registry.Transform(ctx, transformType, transformInfo.Config)
+		if err != nil {
+			return fmt.Errorf("initializing transform: %s", err)
 		}
-		processorChain = append(processorChain, output)
+		processorChain = append(processorChain, observeProcessor(processInfo.Name, "transform", transformType, transform, nil))
+	}
 
-		log.Printf("  Initializing data pipeline")
-		pipeline := ratchet.NewPipeline(context.TODO(), func() {}, processorChain...)
+	// Initialize Output
+	outputType := strings.ToLower(processInfo.Output.Type)
+	stats.OutputType = outputType
+	log.Printf("  Initializing %s output", outputType)
 
-		log.Printf("  Processesing...")
+	output, err := registry.Output(ctx, outputType, processInfo.Output.Config)
+	if err != nil {
+		return fmt.Errorf("initializing output: %s", err)
+	}
+	processorChain = append(processorChain, observeProcessor(processInfo.Name, "output", outputType, output, &stats.RowsOut))
 
-		err = <-pipeline.Run()
-		if err != nil {
-			log.Fatalf("An error occurred in the data pipeline: %s", err.Error())
-		}
+	log.Printf("  Initializing data pipeline")
+	pipeline := ratchet.NewPipeline(processorChain...)
 
-		//log.Println(pipeline.Stats())
-		log.Printf(" Done...")
+	log.Printf("  Processesing...")
 
+	if err := <-pipeline.Run(); err != nil {
+		return fmt.Errorf("pipeline run: %s", err)
 	}
 
+	//log.Println(pipeline.Stats())
 	return nil
 }
 
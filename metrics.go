@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/licaonfee/ratchet/data"
+	"github.com/licaonfee/ratchet/processors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	rowsInTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "etlcmd_rows_in_total",
+		Help: "Total number of rows read by a process's input.",
+	}, []string{"process", "type"})
+
+	rowsOutTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "etlcmd_rows_out_total",
+		Help: "Total number of rows written by a process's output.",
+	}, []string{"process", "type"})
+
+	processErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "etlcmd_process_errors_total",
+		Help: "Total number of process runs that failed.",
+	}, []string{"process"})
+
+	processDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "etlcmd_process_duration_seconds",
+		Help: "Duration of one attempt at running a process's full pipeline.",
+	}, []string{"process"})
+
+	batchDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "etlcmd_batch_duration_seconds",
+		Help: "Duration of a single processor's handling of one batch of rows.",
+	}, []string{"process", "type"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		rowsInTotal,
+		rowsOutTotal,
+		processErrorsTotal,
+		processDurationSeconds,
+		batchDurationSeconds,
+	)
+}
+
+// startMetricsServer starts an HTTP server exposing the etlcmd_* metrics
+// at /metrics on info.Listen. It runs for the remaining lifetime of the
+// process.
+func startMetricsServer(info *metricsInfo) error {
+	ln, err := net.Listen("tcp", info.Listen)
+	if err != nil {
+		return fmt.Errorf("starting metrics server on (%s): %s", info.Listen, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			log.Printf("metrics server stopped: %s", err)
+		}
+	}()
+
+	log.Printf("Serving metrics on %s/metrics", info.Listen)
+	return nil
+}
+
+// rowCount returns the number of rows encoded in d, or 0 if d doesn't
+// decode as a JSON array of objects.
+func rowCount(d data.JSON) int {
+	var rows []map[string]interface{}
+	if err := data.ParseJSON(d, &rows); err != nil {
+		return 0
+	}
+	return len(rows)
+}
+
+// observingProcessor wraps a processors.DataProcessor to record
+// etlcmd_batch_duration_seconds for every batch it handles, and
+// etlcmd_rows_in_total/etlcmd_rows_out_total (and, if rowCount is
+// non-nil, a local running total used for the Markdown report) for
+// input and output stages.
+type observingProcessor struct {
+	processors.DataProcessor
+	process  string
+	kind     string // "input", "transform", or "output"
+	procType string
+	rowCount *int
+}
+
+func (p *observingProcessor) ProcessData(d data.JSON, outputChan chan data.JSON, killChan chan error) {
+	start := time.Now()
+
+	if p.kind == "output" {
+		p.DataProcessor.ProcessData(d, outputChan, killChan)
+		n := rowCount(d)
+		rowsOutTotal.WithLabelValues(p.process, p.procType).Add(float64(n))
+		if p.rowCount != nil {
+			*p.rowCount += n
+		}
+	} else {
+		// Input and transform stages emit their rows to outputChan
+		// rather than receiving them via d, so count by relaying
+		// through a channel of our own.
+		counted := make(chan data.JSON)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for batch := range counted {
+				if p.kind == "input" {
+					n := rowCount(batch)
+					rowsInTotal.WithLabelValues(p.process, p.procType).Add(float64(n))
+					if p.rowCount != nil {
+						*p.rowCount += n
+					}
+				}
+				outputChan <- batch
+			}
+		}()
+		p.DataProcessor.ProcessData(d, counted, killChan)
+		close(counted)
+		<-done
+	}
+
+	batchDurationSeconds.WithLabelValues(p.process, p.procType).Observe(time.Since(start).Seconds())
+}
+
+// observeProcessor wraps dp so its batches are reflected in the
+// etlcmd_* metrics. rowCount, if non-nil, additionally accumulates the
+// rows read (kind == "input") or written (kind == "output") for the
+// Markdown report.
+func observeProcessor(process, kind, procType string, dp processors.DataProcessor, rowCount *int) processors.DataProcessor {
+	return &observingProcessor{
+		DataProcessor: dp,
+		process:       process,
+		kind:          kind,
+		procType:      procType,
+		rowCount:      rowCount,
+	}
+}
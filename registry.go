@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/licaonfee/ratchet/processors"
+	procs "github.com/samhug/ratchet_processors"
+	"github.com/samhug/udt"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/samhug/etlcmd/blob"
+)
+
+// factoryContext carries everything a processorFactory needs besides the
+// block's own attributes: the root Config (for cross-cutting settings
+// like 'unidata' or 'storage'), and a place to register any io.Closer
+// that must outlive the factory call (e.g. an opened file or blob
+// stream), closed once the owning process's pipeline has finished.
+type factoryContext struct {
+	Config  *Config
+	closers *[]io.Closer
+}
+
+func (c *factoryContext) addCloser(closer io.Closer) {
+	*c.closers = append(*c.closers, closer)
+}
+
+// processorFactory builds a processors.DataProcessor from a block's parsed
+// HCL attributes.
+type processorFactory func(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error)
+
+// ProcessorRegistry maps an 'input'/'transform'/'output' block type name
+// to the factory that builds it. The built-in types (csv, json, jsonl,
+// unidata, s3, gcs, azblob, js) are registered by newProcessorRegistry;
+// 'extension' blocks register additional factories on top of it.
+type ProcessorRegistry struct {
+	inputs     map[string]processorFactory
+	transforms map[string]processorFactory
+	outputs    map[string]processorFactory
+}
+
+func newProcessorRegistry() *ProcessorRegistry {
+	r := &ProcessorRegistry{
+		inputs:     make(map[string]processorFactory),
+		transforms: make(map[string]processorFactory),
+		outputs:    make(map[string]processorFactory),
+	}
+	registerBuiltinProcessors(r)
+	return r
+}
+
+// RegisterInput adds or replaces the factory used for an 'input' block
+// of the given type name.
+func (r *ProcessorRegistry) RegisterInput(name string, f processorFactory) {
+	r.inputs[strings.ToLower(name)] = f
+}
+
+// RegisterTransform adds or replaces the factory used for a 'transform'
+// block of the given type name.
+func (r *ProcessorRegistry) RegisterTransform(name string, f processorFactory) {
+	r.transforms[strings.ToLower(name)] = f
+}
+
+// RegisterOutput adds or replaces the factory used for an 'output' block
+// of the given type name.
+func (r *ProcessorRegistry) RegisterOutput(name string, f processorFactory) {
+	r.outputs[strings.ToLower(name)] = f
+}
+
+// Input builds the DataProcessor for an 'input' block.
+func (r *ProcessorRegistry) Input(ctx *factoryContext, name string, blockConfig configMap) (processors.DataProcessor, error) {
+	f, ok := r.inputs[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported input type (%s)", name)
+	}
+	return f(ctx, blockConfig)
+}
+
+// Transform builds the DataProcessor for a 'transform' block.
+func (r *ProcessorRegistry) Transform(ctx *factoryContext, name string, blockConfig configMap) (processors.DataProcessor, error) {
+	f, ok := r.transforms[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported transform type (%s)", name)
+	}
+	return f(ctx, blockConfig)
+}
+
+// Output builds the DataProcessor for an 'output' block.
+func (r *ProcessorRegistry) Output(ctx *factoryContext, name string, blockConfig configMap) (processors.DataProcessor, error) {
+	f, ok := r.outputs[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output type (%s)", name)
+	}
+	return f(ctx, blockConfig)
+}
+
+// registerBuiltinProcessors wires up the processor types etlcmd has
+// always supported natively.
+func registerBuiltinProcessors(r *ProcessorRegistry) {
+	r.RegisterInput("csv", csvInputFactory)
+	r.RegisterInput("json", jsonInputFactory)
+	r.RegisterInput("jsonl", jsonlInputFactory)
+	r.RegisterInput("unidata", unidataInputFactory)
+	for _, blobType := range []string{"s3", "gcs", "azblob"} {
+		blobType := blobType
+		r.RegisterInput(blobType, func(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+			return blobInputFactory(blobType, ctx, blockConfig)
+		})
+		r.RegisterOutput(blobType, func(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+			return blobOutputFactory(blobType, ctx, blockConfig)
+		})
+	}
+
+	r.RegisterTransform("js", jsTransformFactory)
+
+	r.RegisterOutput("csv", csvOutputFactory)
+	r.RegisterOutput("json", jsonOutputFactory)
+	r.RegisterOutput("jsonl", jsonlOutputFactory)
+}
+
+func csvInputFactory(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+	f, err := inputFile(blockConfig)
+	if err != nil {
+		return nil, err
+	}
+	ctx.addCloser(f)
+	return procs.NewCSVReader(f)
+}
+
+func jsonInputFactory(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+	f, err := inputFile(blockConfig)
+	if err != nil {
+		return nil, err
+	}
+	ctx.addCloser(f)
+	return procs.NewJSONReader(f), nil
+}
+
+func jsonlInputFactory(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+	f, err := inputFile(blockConfig)
+	if err != nil {
+		return nil, err
+	}
+	ctx.addCloser(f)
+	return procs.NewJSONLReader(f), nil
+}
+
+func blobInputFactory(blobType string, ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+	bucket, key, format, err := blobLocationAndFormat(blobType, blockConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := blob.Open(blobURL(blobType, bucket, key), blobStorageConfig(ctx.Config))
+	if err != nil {
+		return nil, fmt.Errorf("opening %s input (%s/%s): %s", blobType, bucket, key, err)
+	}
+	ctx.addCloser(r)
+
+	switch format {
+	case "csv":
+		return procs.NewCSVReader(r)
+	case "json":
+		return procs.NewJSONReader(r), nil
+	case "jsonl":
+		return procs.NewJSONLReader(r), nil
+	default:
+		return nil, fmt.Errorf("unsupported format (%s) for %s input", format, blobType)
+	}
+}
+
+func blobOutputFactory(blobType string, ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+	bucket, key, format, err := blobLocationAndFormat(blobType, blockConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := blob.Create(blobURL(blobType, bucket, key), blobStorageConfig(ctx.Config))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s output (%s/%s): %s", blobType, bucket, key, err)
+	}
+	ctx.addCloser(w)
+
+	switch format {
+	case "csv":
+		columnOrder, err := csvColumnOrder(blockConfig)
+		if err != nil {
+			return nil, err
+		}
+		return newCSVWriter(w, columnOrder), nil
+	case "json":
+		return procs.NewJSONWriter(w), nil
+	case "jsonl":
+		return procs.NewJSONLWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported format (%s) for %s output", format, blobType)
+	}
+}
+
+func unidataInputFactory(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+	config := ctx.Config
+	if config.Unidata == nil {
+		return nil, fmt.Errorf("you must specify a top-level 'unidata' block to use input type 'unidata'")
+	}
+
+	udtEnv := &procs.UdtEnvConfig{
+		UdtBin:  config.Unidata.UdtBin,
+		UdtHome: config.Unidata.UdtHome,
+		UdtAcct: config.Unidata.UdtAcct,
+	}
+	if udtEnv.UdtBin == "" {
+		return nil, fmt.Errorf("the 'udtbin' attribute for input type 'unidata' must not be empty")
+	}
+	if udtEnv.UdtHome == "" {
+		return nil, fmt.Errorf("the 'udthome' attribute for input type 'unidata' must not be empty")
+	}
+	if udtEnv.UdtAcct == "" {
+		return nil, fmt.Errorf("the 'udtacct' attribute for input type 'unidata' must not be empty")
+	}
+
+	fileField, ok := blockConfig["file"]
+	if !ok {
+		return nil, fmt.Errorf("you must specify a 'file' attribute for input type 'unidata'")
+	}
+	file, ok := fileField.(string)
+	if !ok {
+		return nil, fmt.Errorf("the 'file' attribute for input type 'unidata' must be a string")
+	}
+
+	// If there is a select statement provided, use it. Otherwise, default to selecting the whole file.
+	var selectScript []string
+	selectStmtField, ok := blockConfig["select"]
+	if ok {
+		// Check if we were given a list
+		selectInterface, ok := selectStmtField.([]interface{})
+		if ok {
+			selectScript = make([]string, len(selectInterface))
+			for i, v := range selectInterface {
+				selectScript[i], ok = v.(string)
+				if !ok {
+					return nil, fmt.Errorf("the 'select' attribute for input type 'unidata' must be a string or an array of strings")
+				}
+			}
+		} else {
+			// Check if we were given a single string
+			selectStmt, ok := selectStmtField.(string)
+			if !ok {
+				return nil, fmt.Errorf("the 'select' attribute for input type 'unidata' must be a string or an array of strings")
+			}
+			selectScript = []string{selectStmt}
+		}
+	} else {
+		selectScript = []string{fmt.Sprintf("SELECT %s", file)}
+	}
+
+	fieldsField, ok := blockConfig["fields"]
+	if !ok {
+		return nil, fmt.Errorf("you must specify a 'fields' attribute for input type 'unidata'")
+	}
+	fieldsInterface, ok := fieldsField.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the 'fields' attribute for input type 'unidata' must be a list of strings")
+	}
+	fields := make([]string, len(fieldsInterface))
+	for i, v := range fieldsInterface {
+		fields[i], ok = v.(string)
+		if !ok {
+			return nil, fmt.Errorf("the 'fields' attribute for input type 'unidata' must be a list of strings")
+		}
+	}
+
+	batchSize := 10000
+	batchSizeField, ok := blockConfig["batch_size"]
+	if ok {
+		batchSize, ok = batchSizeField.(int)
+		if !ok {
+			return nil, fmt.Errorf("the 'batch_size' attribute for input type 'unidata' must be an int")
+		}
+	}
+
+	checkpointPath, err := unidataCheckpointPath(blockConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var store *checkpointStore
+	if checkpointPath != "" {
+		store = newCheckpointStore(checkpointPath)
+
+		lastID, err := store.Load()
+		if err != nil {
+			return nil, err
+		}
+		if lastID != "" {
+			selectScript[len(selectScript)-1] += fmt.Sprintf(" WITH %s > '%s'", fields[0], lastID)
+		}
+	}
+
+	queryConfig := &procs.UdtQueryConfig{
+		Select:    selectScript,
+		File:      file,
+		Fields:    fields,
+		BatchSize: batchSize,
+	}
+
+	authMethods, err := unidataAuthMethods(config.Unidata)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := unidataHostKeyCallback(config.Unidata)
+	if err != nil {
+		return nil, err
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		User:              config.Unidata.Username,
+		Auth:              authMethods,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: config.Unidata.HostKeyAlgorithms,
+	}
+
+	sshClient, err := ssh.Dial("tcp", config.Unidata.Host, sshConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to (%s) as user (%s): %s", config.Unidata.Host, config.Unidata.Username, err)
+	}
+	ctx.addCloser(sshClient)
+
+	udtClient := udt.NewClient(sshClient, udtEnv)
+
+	reader, err := procs.NewUdtReader(udtClient, queryConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if store != nil {
+		return &checkpointingProcessor{DataProcessor: reader, store: store, idField: fields[0]}, nil
+	}
+	return reader, nil
+}
+
+func jsTransformFactory(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+	script, ok := blockConfig["script"].(string)
+	if !ok {
+		return nil, fmt.Errorf("the 'script' attribute for transform type 'js' must be a string")
+	}
+	return procs.NewJsTransform(script)
+}
+
+func csvOutputFactory(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+	f, err := outputFile(blockConfig)
+	if err != nil {
+		return nil, err
+	}
+	ctx.addCloser(f)
+	columnOrder, err := csvColumnOrder(blockConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newCSVWriter(f, columnOrder), nil
+}
+
+func jsonOutputFactory(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+	f, err := outputFile(blockConfig)
+	if err != nil {
+		return nil, err
+	}
+	ctx.addCloser(f)
+	return procs.NewJSONWriter(f), nil
+}
+
+func jsonlOutputFactory(ctx *factoryContext, blockConfig configMap) (processors.DataProcessor, error) {
+	f, err := outputFile(blockConfig)
+	if err != nil {
+		return nil, err
+	}
+	ctx.addCloser(f)
+	return procs.NewJSONLWriter(f), nil
+}
+
+// csvColumnOrder extracts the optional 'column_order' attribute shared by
+// the csv output and blob-backed csv output factories.
+func csvColumnOrder(blockConfig configMap) ([]string, error) {
+	var columnOrder []string
+	if blockConfig["column_order"] == nil {
+		return nil, nil
+	}
+	v, ok := blockConfig["column_order"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("field 'column_order' for csv output must be an array of strings")
+	}
+	for i, c := range v {
+		h, ok := c.(string)
+		if !ok {
+			return nil, fmt.Errorf("field 'column_order' for csv output: item %d must be a string", i)
+		}
+		columnOrder = append(columnOrder, h)
+	}
+	return columnOrder, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// processStats captures the summary data recorded for a single process
+// run, written out by appendReport as one row of the Markdown report.
+type processStats struct {
+	Name       string
+	InputType  string
+	RowsIn     int
+	Transforms []string
+	OutputType string
+	RowsOut    int
+	Duration   time.Duration
+	Err        error
+}
+
+const reportHeader = "| Process | Input | Rows In | Transforms | Output | Rows Out | Duration | Error |\n" +
+	"|---|---|---|---|---|---|---|---|\n"
+
+// appendReport appends one Markdown table row summarizing stats to
+// info.Path, writing the table header first if the file doesn't exist
+// yet. It does nothing if info is nil.
+func appendReport(info *reportInfo, stats *processStats) error {
+	if info == nil {
+		return nil
+	}
+
+	transforms := strings.Join(stats.Transforms, ", ")
+	if transforms == "" {
+		transforms = "-"
+	}
+
+	errMsg := "-"
+	if stats.Err != nil {
+		errMsg = stats.Err.Error()
+	}
+
+	row := fmt.Sprintf("| %s | %s | %d | %s | %s | %d | %s | %s |\n",
+		stats.Name, stats.InputType, stats.RowsIn, transforms, stats.OutputType, stats.RowsOut,
+		stats.Duration.Round(time.Millisecond), errMsg)
+
+	var buf strings.Builder
+	if _, err := os.Stat(info.Path); os.IsNotExist(err) {
+		buf.WriteString(reportHeader)
+	}
+	buf.WriteString(row)
+
+	// Write the header (if any) and row in a single call so that
+	// concurrent invocations of etlcmd appending to the same report
+	// file don't interleave partial rows.
+	f, err := os.OpenFile(info.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening report (%s): %s", info.Path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(buf.String()); err != nil {
+		return fmt.Errorf("writing report (%s): %s", info.Path, err)
+	}
+	return nil
+}
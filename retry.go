@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// retryPolicy controls how many times a process's pipeline is retried,
+// and the exponential backoff with jitter applied between attempts.
+type retryPolicy struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+var defaultRetryPolicy = retryPolicy{
+	Attempts:       1,
+	InitialBackoff: 5 * time.Second,
+	MaxBackoff:     5 * time.Minute,
+	Multiplier:     2.0,
+}
+
+// resolveRetryPolicy overlays a parsed 'retry' block (which may be nil,
+// meaning no block was configured) onto defaultRetryPolicy.
+func resolveRetryPolicy(info *retryInfo) (*retryPolicy, error) {
+	policy := defaultRetryPolicy
+	if info == nil {
+		return &policy, nil
+	}
+
+	if info.Attempts > 0 {
+		policy.Attempts = info.Attempts
+	}
+	if info.InitialBackoff != "" {
+		d, err := time.ParseDuration(info.InitialBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'initial_backoff' (%s): %s", info.InitialBackoff, err)
+		}
+		policy.InitialBackoff = d
+	}
+	if info.MaxBackoff != "" {
+		d, err := time.ParseDuration(info.MaxBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'max_backoff' (%s): %s", info.MaxBackoff, err)
+		}
+		policy.MaxBackoff = d
+	}
+	if info.Multiplier > 0 {
+		policy.Multiplier = info.Multiplier
+	}
+
+	return &policy, nil
+}
+
+// runWithRetry calls run until it succeeds or policy's attempt count is
+// exhausted, sleeping for an exponentially increasing, jittered backoff
+// between attempts. name is used to prefix log output.
+func runWithRetry(name string, policy *retryPolicy, run func() error) error {
+	backoff := policy.InitialBackoff
+
+	var lastErr error
+	for attempt := 1; attempt <= policy.Attempts; attempt++ {
+		lastErr = run()
+		if lastErr == nil {
+			return nil
+		}
+
+		log.Printf("%s: attempt %d/%d failed: %s", name, attempt, policy.Attempts, lastErr)
+		if attempt == policy.Attempts {
+			break
+		}
+
+		sleep := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2+1)))
+		log.Printf("%s: retrying in %s", name, sleep)
+		time.Sleep(sleep)
+
+		backoff = time.Duration(float64(backoff) * policy.Multiplier)
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+
+	return fmt.Errorf("%s: failed after %d attempt(s): %s", name, policy.Attempts, lastErr)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// unidataHostKeyCallback builds the ssh.HostKeyCallback used to connect
+// to the unidata host, verifying against 'known_hosts' unless the user
+// has explicitly opted out via 'insecure_skip_host_key_check'.
+func unidataHostKeyCallback(u *unidataInfo) (ssh.HostKeyCallback, error) {
+	if u.InsecureSkipHostKeyCheck {
+		log.Printf("WARNING: SSH host key verification is disabled for the unidata connection (insecure_skip_host_key_check = true)")
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	if u.KnownHosts == "" {
+		return nil, fmt.Errorf("you must specify 'known_hosts' for input type 'unidata', or set 'insecure_skip_host_key_check = true'")
+	}
+
+	callback, err := knownhosts.New(u.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("loading known_hosts (%s): %s", u.KnownHosts, err)
+	}
+
+	return callback, nil
+}
+
+// unidataAuthMethods builds the ssh.AuthMethod list used to connect to
+// the unidata host from whatever combination of password, private key,
+// and ssh-agent the user configured.
+func unidataAuthMethods(u *unidataInfo) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if u.Password != "" {
+		methods = append(methods, ssh.Password(u.Password))
+	}
+
+	if u.PrivateKey != "" {
+		keyBytes, err := os.ReadFile(u.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("reading private_key (%s): %s", u.PrivateKey, err)
+		}
+
+		var signer ssh.Signer
+		if u.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(keyBytes, []byte(u.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(keyBytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing private_key (%s): %s", u.PrivateKey, err)
+		}
+
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if u.SSHAgent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, fmt.Errorf("ssh_agent is enabled but SSH_AUTH_SOCK is not set")
+		}
+
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to ssh-agent (%s): %s", sock, err)
+		}
+
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("you must configure at least one of 'password', 'private_key', or 'ssh_agent' for input type 'unidata'")
+	}
+
+	return methods, nil
+}